@@ -3,53 +3,67 @@ package main
 import (
 	"bufio"
 	"context"
-	"crypto/tls"
 	"flag"
 	"fmt"
-	"io"
-	"net"
 	"net/http"
-	"net/url"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
+	"syscall"
 	"time"
 
-	"golang.org/x/net/proxy"
+	"github.com/sphinxid/sopan/proxypool"
 )
 
-const (
-	defaultTestURL = "https://kodelatte.com/"
-	defaultTimeout = 5 * time.Second
-)
-
-// ProxyInfo holds proxy connection details
-type ProxyInfo struct {
-	Host     string
-	Port     string
-	Username string
-	Password string
-	Raw      string
-}
-
-// TestResult holds the result of a proxy test
-type TestResult struct {
-	Proxy   string
-	Success bool
-	Latency time.Duration
-	Error   string
-}
-
 var (
-	proxyFlag    = flag.String("proxy", "", "Single proxy to test (format: socks5://[user:pass@]host:port)")
+	proxyFlag    = flag.String("proxy", "", "Single proxy to test (format: [scheme://][user:pass@]host:port, scheme one of http, https, socks4, socks4a, socks5, ssh; defaults to socks5)")
 	fileFlag     = flag.String("file", "", "File containing list of proxies (one per line)")
 	timeoutFlag  = flag.Int("timeout", 5, "Timeout in seconds for each proxy test")
-	threadsFlag  = flag.Int("threads", 10, "Number of concurrent threads")
+	threadsFlag  = flag.Int("threads", 10, "Maximum concurrent proxy tests; the adaptive scheduler starts at max(1, threads/4) in-flight and grows towards this ceiling as tests succeed")
 	outputFlag   = flag.String("output", "", "Output file for successful proxies (optional)")
 	verboseFlag  = flag.Bool("verbose", false, "Verbose output (show all results)")
-	testURLFlag  = flag.String("url", defaultTestURL, "URL to test proxies against")
+	testsFlag    = flag.String("tests", "https", "Comma-separated probes to run per proxy: http, https, tcp:host:port, udp, ipv6")
+	classifyFlag = flag.Bool("classify", false, "Classify each successful proxy's anonymity level (transparent/anonymous/elite)")
+	judgeURLFlag stringSliceFlag
+
+	daemonFlag     = flag.Bool("daemon", false, "Run as a long-lived daemon that continuously rechecks -file and serves an HTTP API")
+	intervalFlag   = flag.Int("interval", 60, "Daemon: seconds between recheck passes")
+	evictAfterFlag = flag.Int("evict-after", 3, "Daemon: consecutive failures before a proxy is evicted from the pool")
+	cooldownFlag   = flag.Int("cooldown", 300, "Daemon: seconds an evicted proxy waits before being retried")
+	listenFlag     = flag.String("listen", ":8080", "Daemon: address to serve the HTTP API on")
+
+	geoIPDBFlag        = flag.String("geoip-db", "", "Path to a MaxMind GeoLite2 (City or ASN) database for exit IP enrichment")
+	countryFlag        = flag.String("country", "", "Comma-separated country code allow-list (e.g. US,DE,JP), requires -geoip-db")
+	excludeCountryFlag = flag.String("exclude-country", "", "Comma-separated country code deny-list, requires -geoip-db")
+	asnFlag            = flag.String("asn", "", "Comma-separated ASN allow-list, requires -geoip-db")
+	groupByFlag        = flag.String("group-by", "", "Group displayed results by field (currently only \"country\")")
+
+	formatFlag = flag.String("format", "text", "Output format: text, json, jsonl, csv")
+
+	rateFlag        = flag.Float64("rate", 0, "Global rate limit in requests/sec across all proxies (0 = unlimited)")
+	perHostRateFlag = flag.Float64("per-host-rate", 0, "Per-proxy-host rate limit in requests/sec (0 = unlimited)")
 )
 
+var supportedFormats = map[string]bool{"text": true, "json": true, "jsonl": true, "csv": true}
+
+func init() {
+	flag.Var(&judgeURLFlag, "judge-url", "Judge URL returning the client-visible IP and headers as JSON (repeatable, used with -classify)")
+}
+
+// stringSliceFlag implements flag.Value to collect a repeatable flag into a slice.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
 func main() {
 	flag.Parse()
 
@@ -59,11 +73,55 @@ func main() {
 		os.Exit(1)
 	}
 
+	if !supportedFormats[*formatFlag] {
+		fmt.Printf("Error: unsupported -format %q (want text, json, jsonl, or csv)\n", *formatFlag)
+		os.Exit(1)
+	}
+
+	if *geoIPDBFlag == "" && (*countryFlag != "" || *excludeCountryFlag != "" || *asnFlag != "") {
+		fmt.Println("Error: -country, -exclude-country, and -asn require -geoip-db")
+		os.Exit(1)
+	}
+
 	timeout := time.Duration(*timeoutFlag) * time.Second
-	
+
+	cfg := proxypool.Config{
+		Timeout:     timeout,
+		Tests:       splitCSV(*testsFlag),
+		Classify:    *classifyFlag,
+		JudgeURLs:   []string(judgeURLFlag),
+		GlobalRate:  *rateFlag,
+		PerHostRate: *perHostRateFlag,
+	}
+
+	if cfg.Classify {
+		if len(cfg.JudgeURLs) == 0 {
+			cfg.JudgeURLs = proxypool.DefaultJudgeURLs
+		}
+		realIP, err := proxypool.FetchPublicIP(cfg.JudgeURLs, timeout)
+		if err != nil {
+			fmt.Printf("Warning: failed to determine real public IP, transparent proxies won't be detected: %v\n", err)
+		}
+		cfg.RealIP = realIP
+	}
+
+	if *geoIPDBFlag != "" {
+		geo, err := proxypool.OpenGeoIP(*geoIPDBFlag)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer geo.Close()
+		cfg.GeoIP = geo
+	}
+
+	geoFilter := proxypool.GeoFilter{
+		Countries:        splitCSV(*countryFlag),
+		ExcludeCountries: splitCSV(*excludeCountryFlag),
+		ASNs:             parseASNs(*asnFlag),
+	}
+
 	var proxies []string
-	
-	// Load proxies
 	if *proxyFlag != "" {
 		proxies = []string{*proxyFlag}
 	} else {
@@ -80,197 +138,127 @@ func main() {
 		os.Exit(1)
 	}
 
-	fmt.Printf("Testing %d proxies with %d threads (timeout: %v)\n", len(proxies), *threadsFlag, timeout)
-	fmt.Printf("Test URL: %s\n", *testURLFlag)
-	fmt.Println(strings.Repeat("-", 80))
-
-	// Test proxies
-	results := testProxies(proxies, *threadsFlag, timeout, *testURLFlag)
-
-	// Display results
-	displayResults(results)
-
-	// Save successful proxies if output file specified
-	if *outputFlag != "" {
-		saveSuccessfulProxies(results, *outputFlag)
-	}
-}
-
-// loadProxiesFromFile reads proxies from a file
-func loadProxiesFromFile(filename string) ([]string, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, err
+	if *daemonFlag {
+		runDaemon(proxies, cfg, geoFilter)
+		return
 	}
-	defer file.Close()
 
-	var proxies []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" && !strings.HasPrefix(line, "#") {
-			proxies = append(proxies, line)
+	if *formatFlag == "jsonl" {
+		if err := runJSONL(proxies, *threadsFlag, cfg, cfg.GeoIP != nil, geoFilter, *outputFlag); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
 		}
+		return
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
-	}
-
-	return proxies, nil
-}
-
-// parseProxy parses a proxy string into ProxyInfo
-func parseProxy(proxyStr string) (*ProxyInfo, error) {
-	// Handle both with and without protocol prefix
-	if !strings.HasPrefix(proxyStr, "socks5://") && !strings.HasPrefix(proxyStr, "socks4://") {
-		proxyStr = "socks5://" + proxyStr
-	}
-
-	u, err := url.Parse(proxyStr)
-	if err != nil {
-		return nil, fmt.Errorf("invalid proxy format: %v", err)
+	if *formatFlag == "text" {
+		fmt.Printf("Testing %d proxies with %d threads (timeout: %v)\n", len(proxies), *threadsFlag, timeout)
+		fmt.Printf("Tests: %s\n", strings.Join(cfg.Tests, ", "))
+		fmt.Println(strings.Repeat("-", 80))
 	}
 
-	info := &ProxyInfo{
-		Host: u.Hostname(),
-		Port: u.Port(),
-		Raw:  proxyStr,
-	}
+	results, stats := proxypool.TestProxiesWithStats(proxies, *threadsFlag, cfg, nil)
+	results = filterByGeo(results, cfg.GeoIP != nil, geoFilter)
 
-	if u.User != nil {
-		info.Username = u.User.Username()
-		info.Password, _ = u.User.Password()
+	if *verboseFlag {
+		fmt.Printf("Scheduler: peak in-flight %d, target %d, rate-limit waits %d\n", stats.Peak(), stats.Target(), stats.Rejected())
 	}
 
-	if info.Host == "" || info.Port == "" {
-		return nil, fmt.Errorf("invalid proxy format: missing host or port")
-	}
-
-	return info, nil
-}
-
-// testProxy tests a single proxy
-func testProxy(proxyStr string, timeout time.Duration, testURL string) TestResult {
-	result := TestResult{
-		Proxy:   proxyStr,
-		Success: false,
-	}
-
-	proxyInfo, err := parseProxy(proxyStr)
-	if err != nil {
-		result.Error = err.Error()
-		return result
-	}
-
-	start := time.Now()
-
-	// Create SOCKS5 dialer
-	var auth *proxy.Auth
-	if proxyInfo.Username != "" {
-		auth = &proxy.Auth{
-			User:     proxyInfo.Username,
-			Password: proxyInfo.Password,
+	switch *formatFlag {
+	case "json":
+		if err := writeJSONResults(results, *outputFlag); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "csv":
+		if err := writeCSVResults(results, *outputFlag); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	default:
+		if *groupByFlag == "country" {
+			displayResultsByCountry(results)
+		} else {
+			displayResults(results)
+		}
+		if *outputFlag != "" {
+			saveSuccessfulProxies(results, *outputFlag)
 		}
 	}
+}
 
-	dialer, err := proxy.SOCKS5("tcp", net.JoinHostPort(proxyInfo.Host, proxyInfo.Port), auth, proxy.Direct)
-	if err != nil {
-		result.Error = fmt.Sprintf("failed to create dialer: %v", err)
-		return result
+// runDaemon starts a Pool that rechecks proxies on an interval and serves it
+// over HTTP until interrupted.
+func runDaemon(proxies []string, cfg proxypool.Config, geoFilter proxypool.GeoFilter) {
+	poolCfg := proxypool.PoolConfig{
+		Proxies:    proxies,
+		Interval:   time.Duration(*intervalFlag) * time.Second,
+		Threads:    *threadsFlag,
+		EvictAfter: *evictAfterFlag,
+		Cooldown:   time.Duration(*cooldownFlag) * time.Second,
+		Test:       cfg,
+		GeoActive:  cfg.GeoIP != nil,
+		GeoFilter:  geoFilter,
 	}
 
-	// Create HTTP client with SOCKS5 proxy
-	transport := &http.Transport{
-		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-			return dialer.Dial(network, addr)
-		},
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: false,
-		},
-		DisableKeepAlives: true,
-	}
+	pool := proxypool.NewPool(poolCfg)
 
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   timeout,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pool.Run(ctx)
 
-	// Make request
-	req, err := http.NewRequest("GET", testURL, nil)
-	if err != nil {
-		result.Error = fmt.Sprintf("failed to create request: %v", err)
-		return result
+	server := &http.Server{
+		Addr:    *listenFlag,
+		Handler: proxypool.NewServer(pool).Handler(),
 	}
 
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 SOPAN/1.0")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		result.Error = fmt.Sprintf("request failed: %v", err)
-		return result
-	}
-	defer resp.Body.Close()
+	go func() {
+		fmt.Printf("Serving %d proxies on %s (recheck every %v, evict after %d failures, %v cooldown)\n",
+			len(proxies), *listenFlag, poolCfg.Interval, poolCfg.EvictAfter, poolCfg.Cooldown)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Printf("Error: HTTP server failed: %v\n", err)
+			os.Exit(1)
+		}
+	}()
 
-	// Read response body (to ensure full connection)
-	_, err = io.Copy(io.Discard, resp.Body)
-	if err != nil {
-		result.Error = fmt.Sprintf("failed to read response: %v", err)
-		return result
-	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
 
-	result.Latency = time.Since(start)
-	result.Success = resp.StatusCode >= 200 && resp.StatusCode < 400
-	
-	if !result.Success {
-		result.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
-	}
+	fmt.Println("\nShutting down...")
+	cancel()
 
-	return result
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
+	server.Shutdown(shutdownCtx)
 }
 
-// testProxies tests multiple proxies concurrently
-func testProxies(proxies []string, threads int, timeout time.Duration, testURL string) []TestResult {
-	var wg sync.WaitGroup
-	resultsChan := make(chan TestResult, len(proxies))
-	proxyChan := make(chan string, len(proxies))
-
-	// Start worker goroutines
-	for i := 0; i < threads; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for proxyStr := range proxyChan {
-				result := testProxy(proxyStr, timeout, testURL)
-				resultsChan <- result
-			}
-		}()
+// loadProxiesFromFile reads proxies from a file
+func loadProxiesFromFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
 	}
+	defer file.Close()
 
-	// Send proxies to workers
-	for _, p := range proxies {
-		proxyChan <- p
+	var proxies []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			proxies = append(proxies, line)
+		}
 	}
-	close(proxyChan)
-
-	// Wait for all workers to finish
-	wg.Wait()
-	close(resultsChan)
 
-	// Collect results
-	var results []TestResult
-	for result := range resultsChan {
-		results = append(results, result)
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 
-	return results
+	return proxies, nil
 }
 
 // displayResults displays test results
-func displayResults(results []TestResult) {
+func displayResults(results []proxypool.TestResult) {
 	successCount := 0
 	failCount := 0
 
@@ -278,7 +266,12 @@ func displayResults(results []TestResult) {
 		if result.Success {
 			successCount++
 			if *verboseFlag {
-				fmt.Printf("✓ [SUCCESS] %s (latency: %v)\n", result.Proxy, result.Latency)
+				if result.AnonymityLevel != "" {
+					fmt.Printf("✓ [SUCCESS] %s (type: %s, latency: %v, anonymity: %s, exit IP: %s)\n", result.Proxy, result.ProxyType, result.Latency, result.AnonymityLevel, result.ExitIP)
+				} else {
+					fmt.Printf("✓ [SUCCESS] %s (type: %s, latency: %v)\n", result.Proxy, result.ProxyType, result.Latency)
+				}
+				fmt.Printf("             %s\n", summarizeProbes(result.Probes))
 			}
 		} else {
 			failCount++
@@ -301,8 +294,89 @@ func displayResults(results []TestResult) {
 	}
 }
 
+// summarizeProbes renders a proxy's per-probe results as "kind:ok" /
+// "kind:fail" pairs, sorted by kind for stable output.
+func summarizeProbes(probes map[string]proxypool.ProbeResult) string {
+	kinds := make([]string, 0, len(probes))
+	for kind := range probes {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	parts := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		if probes[kind].Success {
+			parts = append(parts, fmt.Sprintf("%s:ok", kind))
+		} else {
+			parts = append(parts, fmt.Sprintf("%s:fail", kind))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitCSV splits a comma-separated flag value into trimmed, non-empty parts.
+func splitCSV(value string) []string {
+	if value == "" {
+		return nil
+	}
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+// parseASNs parses a comma-separated list of ASN numbers from an -asn flag value.
+func parseASNs(value string) []uint {
+	var out []uint
+	for _, part := range splitCSV(value) {
+		n, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			fmt.Printf("Warning: ignoring invalid ASN %q\n", part)
+			continue
+		}
+		out = append(out, uint(n))
+	}
+	return out
+}
+
+// displayResultsByCountry groups successful results by country before
+// printing them, for use with -group-by country.
+func displayResultsByCountry(results []proxypool.TestResult) {
+	groups := map[string][]proxypool.TestResult{}
+	for _, result := range results {
+		if !result.Success {
+			continue
+		}
+		country := result.Country
+		if country == "" {
+			country = "unknown"
+		}
+		groups[country] = append(groups[country], result)
+	}
+
+	countries := make([]string, 0, len(groups))
+	for country := range groups {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+
+	for _, country := range countries {
+		fmt.Printf("\n%s (%d)\n", country, len(groups[country]))
+		for _, result := range groups[country] {
+			fmt.Printf("  %s (latency: %v, org: %s)\n", result.Proxy, result.Latency, result.Org)
+		}
+	}
+
+	fmt.Println(strings.Repeat("-", 80))
+	displayResults(results)
+}
+
 // saveSuccessfulProxies saves successful proxies to a file
-func saveSuccessfulProxies(results []TestResult, filename string) {
+func saveSuccessfulProxies(results []proxypool.TestResult, filename string) {
 	file, err := os.Create(filename)
 	if err != nil {
 		fmt.Printf("Error creating output file: %v\n", err)
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/sphinxid/sopan/proxypool"
+)
+
+// jsonSummary is the header written alongside the results array in -format json.
+type jsonSummary struct {
+	Total      int `json:"total"`
+	Successful int `json:"successful"`
+	Failed     int `json:"failed"`
+}
+
+type jsonOutput struct {
+	Summary jsonSummary            `json:"summary"`
+	Results []proxypool.TestResult `json:"results"`
+}
+
+// openOutput returns a writer for path, or os.Stdout if path is empty, along
+// with a close function the caller should always call.
+func openOutput(path string) (io.Writer, func(), error) {
+	if path == "" {
+		return os.Stdout, func() {}, nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create output file: %v", err)
+	}
+	return f, func() { f.Close() }, nil
+}
+
+// passesGeoFilter reports whether result should be kept when a geo filter is
+// active. Failed results and results that don't match are dropped, since
+// country/ASN filtering only makes sense for proxies that actually worked.
+func passesGeoFilter(result proxypool.TestResult, geoActive bool, filter proxypool.GeoFilter) bool {
+	if !geoActive {
+		return true
+	}
+	return result.Success && filter.Matches(result)
+}
+
+// filterByGeo keeps only results that pass the geo filter, when one is active.
+func filterByGeo(results []proxypool.TestResult, geoActive bool, filter proxypool.GeoFilter) []proxypool.TestResult {
+	if !geoActive {
+		return results
+	}
+	out := make([]proxypool.TestResult, 0, len(results))
+	for _, result := range results {
+		if passesGeoFilter(result, geoActive, filter) {
+			out = append(out, result)
+		}
+	}
+	return out
+}
+
+// writeJSONResults writes results as a single JSON object with a summary
+// header, to path (or stdout if path is empty).
+func writeJSONResults(results []proxypool.TestResult, path string) error {
+	w, closeFn, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	summary := jsonSummary{Total: len(results)}
+	for _, r := range results {
+		if r.Success {
+			summary.Successful++
+		} else {
+			summary.Failed++
+		}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(jsonOutput{Summary: summary, Results: results})
+}
+
+var csvHeader = []string{
+	"proxy", "scheme", "host", "port", "success", "latency_ms", "http_status", "error",
+	"exit_ip", "country", "anonymity", "probes",
+}
+
+// writeCSVResults writes results as CSV to path (or stdout if path is empty).
+func writeCSVResults(results []proxypool.TestResult, path string) error {
+	w, closeFn, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	writer := csv.NewWriter(w)
+	defer writer.Flush()
+
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		record := []string{
+			r.Proxy,
+			r.ProxyType,
+			r.Host,
+			r.Port,
+			strconv.FormatBool(r.Success),
+			strconv.FormatInt(r.Latency.Milliseconds(), 10),
+			strconv.Itoa(r.HTTPStatus),
+			r.Error,
+			r.ExitIP,
+			r.Country,
+			r.AnonymityLevel,
+			summarizeProbes(r.Probes),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// runJSONL tests proxies and writes each TestResult to the output as soon as
+// it completes, instead of buffering the whole batch first.
+func runJSONL(proxies []string, threads int, cfg proxypool.Config, geoActive bool, filter proxypool.GeoFilter, path string) error {
+	w, closeFn, err := openOutput(path)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	enc := json.NewEncoder(w)
+	successCount, failCount := 0, 0
+
+	proxypool.TestProxies(proxies, threads, cfg, func(r proxypool.TestResult) {
+		if !passesGeoFilter(r, geoActive, filter) {
+			return
+		}
+		if r.Success {
+			successCount++
+		} else {
+			failCount++
+		}
+		enc.Encode(r)
+	})
+
+	fmt.Fprintf(os.Stderr, "Results: %d successful | %d failed\n", successCount, failCount)
+	return nil
+}
@@ -0,0 +1,152 @@
+package proxypool
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultJudgeURLs is used when classification is requested without any
+// explicit judge URL.
+var DefaultJudgeURLs = []string{"https://httpbin.org/get"}
+
+// leakHeaders are the request headers judge services echo back that
+// indicate the request passed through a proxy, or reveal the client's real
+// IP even when the proxy itself hides it.
+var leakHeaders = []string{"Via", "X-Forwarded-For", "Forwarded", "X-Real-Ip", "Proxy-Connection"}
+
+// FetchPublicIP determines the machine's real public IP via a direct (no
+// proxy) request to one of the judge URLs, so classifyProxy has something to
+// compare a proxy's exit IP against.
+func FetchPublicIP(judgeURLs []string, timeout time.Duration) (string, error) {
+	client := &http.Client{Timeout: timeout}
+
+	var lastErr error
+	for _, ju := range judgeURLs {
+		ip, _, err := queryJudge(client, ju)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if ip != "" {
+			return ip, nil
+		}
+	}
+	if lastErr != nil {
+		return "", lastErr
+	}
+	return "", fmt.Errorf("no judge URL returned a usable IP")
+}
+
+// classifyProxy queries the judge URLs through client (which is already
+// configured to dial via the proxy under test) and buckets the proxy as
+// "transparent" (leaks the real IP), "anonymous" (hides the real IP but
+// reveals proxy usage via headers), or "elite" (hides both).
+func classifyProxy(client *http.Client, judgeURLs []string, realIP string) (exitIP, level string, leaked []string, err error) {
+	if len(judgeURLs) == 0 {
+		judgeURLs = DefaultJudgeURLs
+	}
+
+	seenHeaders := map[string]string{}
+	for _, ju := range judgeURLs {
+		ip, headers, qErr := queryJudge(client, ju)
+		if qErr != nil {
+			continue
+		}
+		if ip != "" && exitIP == "" {
+			exitIP = ip
+		}
+		for k, v := range headers {
+			seenHeaders[k] = v
+		}
+	}
+
+	if exitIP == "" {
+		return "", "", nil, fmt.Errorf("no judge URL returned a usable response")
+	}
+
+	leaksRealIP := false
+	for _, h := range leakHeaders {
+		if v, ok := seenHeaders[strings.ToLower(h)]; ok && v != "" {
+			leaked = append(leaked, fmt.Sprintf("%s: %s", h, v))
+			for _, field := range strings.FieldsFunc(v, func(r rune) bool { return strings.ContainsRune(`,; ="`, r) }) {
+				if realIP != "" && field == realIP {
+					leaksRealIP = true
+				}
+			}
+		}
+	}
+
+	switch {
+	case realIP != "" && (exitIP == realIP || leaksRealIP):
+		level = "transparent"
+	case len(leaked) > 0:
+		level = "anonymous"
+	default:
+		level = "elite"
+	}
+
+	return exitIP, level, leaked, nil
+}
+
+// queryJudge fetches a judge URL and extracts the client-visible IP and the
+// echoed request headers from its JSON response.
+func queryJudge(client *http.Client, judgeURL string) (ip string, headers map[string]string, err error) {
+	req, err := http.NewRequest("GET", judgeURL, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 SOPAN/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", nil, err
+	}
+
+	return parseJudgeResponse(body)
+}
+
+// parseJudgeResponse extracts the client IP and headers from a judge
+// response, supporting httpbin's {"origin", "headers"} shape and the
+// simpler {"ip"} / {"query"} shape used by services like ipify/ip-api.
+func parseJudgeResponse(body []byte) (string, map[string]string, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return "", nil, fmt.Errorf("invalid judge response: %v", err)
+	}
+
+	ip := ""
+	if v, ok := raw["origin"].(string); ok {
+		ip = strings.TrimSpace(strings.Split(v, ",")[0])
+	}
+	if ip == "" {
+		if v, ok := raw["ip"].(string); ok {
+			ip = v
+		}
+	}
+	if ip == "" {
+		if v, ok := raw["query"].(string); ok {
+			ip = v
+		}
+	}
+
+	headers := map[string]string{}
+	if h, ok := raw["headers"].(map[string]interface{}); ok {
+		for k, v := range h {
+			if s, ok := v.(string); ok {
+				headers[strings.ToLower(k)] = s
+			}
+		}
+	}
+
+	return ip, headers, nil
+}
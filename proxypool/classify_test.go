@@ -0,0 +1,128 @@
+package proxypool
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// judgeServer starts an httptest server returning body as-is, simulating a
+// judge URL like httpbin.org/get that echoes the client's request headers
+// back inside the JSON body (not as real HTTP response headers).
+func judgeServer(t *testing.T, body string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestClassifyProxy(t *testing.T) {
+	t.Run("transparent when exit IP matches the real IP", func(t *testing.T) {
+		srv := judgeServer(t, `{"origin":"203.0.113.9"}`)
+		client := srv.Client()
+
+		exitIP, level, leaked, err := classifyProxy(client, []string{srv.URL}, "203.0.113.9")
+		if err != nil {
+			t.Fatalf("classifyProxy returned error: %v", err)
+		}
+		if exitIP != "203.0.113.9" {
+			t.Errorf("exitIP = %q, want 203.0.113.9", exitIP)
+		}
+		if level != "transparent" {
+			t.Errorf("level = %q, want transparent", level)
+		}
+		if len(leaked) != 0 {
+			t.Errorf("leaked = %v, want none", leaked)
+		}
+	})
+
+	t.Run("anonymous when exit IP differs but proxy headers leak", func(t *testing.T) {
+		srv := judgeServer(t, `{"origin":"198.51.100.4","headers":{"Via":"1.1 proxy"}}`)
+		client := srv.Client()
+
+		_, level, leaked, err := classifyProxy(client, []string{srv.URL}, "203.0.113.9")
+		if err != nil {
+			t.Fatalf("classifyProxy returned error: %v", err)
+		}
+		if level != "anonymous" {
+			t.Errorf("level = %q, want anonymous", level)
+		}
+		if len(leaked) != 1 || leaked[0] != "Via: 1.1 proxy" {
+			t.Errorf("leaked = %v, want [\"Via: 1.1 proxy\"]", leaked)
+		}
+	})
+
+	t.Run("elite when exit IP differs and nothing leaks", func(t *testing.T) {
+		srv := judgeServer(t, `{"origin":"198.51.100.4"}`)
+		client := srv.Client()
+
+		_, level, leaked, err := classifyProxy(client, []string{srv.URL}, "203.0.113.9")
+		if err != nil {
+			t.Fatalf("classifyProxy returned error: %v", err)
+		}
+		if level != "elite" {
+			t.Errorf("level = %q, want elite", level)
+		}
+		if len(leaked) != 0 {
+			t.Errorf("leaked = %v, want none", leaked)
+		}
+	})
+
+	t.Run("no usable response from any judge URL is an error", func(t *testing.T) {
+		srv := judgeServer(t, `not json`)
+		client := srv.Client()
+
+		if _, _, _, err := classifyProxy(client, []string{srv.URL}, ""); err == nil {
+			t.Fatal("classifyProxy succeeded, want error")
+		}
+	})
+}
+
+func TestParseJudgeResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantIP     string
+		wantHeader string
+	}{
+		{
+			name:       "httpbin shape with comma-separated origin",
+			body:       `{"origin":"203.0.113.9, 10.0.0.1","headers":{"Via":"1.1 proxy"}}`,
+			wantIP:     "203.0.113.9",
+			wantHeader: "1.1 proxy",
+		},
+		{
+			name:   "ipify shape",
+			body:   `{"ip":"203.0.113.9"}`,
+			wantIP: "203.0.113.9",
+		},
+		{
+			name:   "ip-api shape",
+			body:   `{"query":"203.0.113.9"}`,
+			wantIP: "203.0.113.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ip, headers, err := parseJudgeResponse([]byte(tt.body))
+			if err != nil {
+				t.Fatalf("parseJudgeResponse returned error: %v", err)
+			}
+			if ip != tt.wantIP {
+				t.Errorf("ip = %q, want %q", ip, tt.wantIP)
+			}
+			if tt.wantHeader != "" && headers["via"] != tt.wantHeader {
+				t.Errorf("headers[via] = %q, want %q", headers["via"], tt.wantHeader)
+			}
+		})
+	}
+
+	t.Run("invalid JSON is an error", func(t *testing.T) {
+		if _, _, err := parseJudgeResponse([]byte("not json")); err == nil {
+			t.Fatal("parseJudgeResponse succeeded, want error")
+		}
+	})
+}
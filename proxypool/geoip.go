@@ -0,0 +1,135 @@
+package proxypool
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoInfo holds the geo/ASN fields a proxy's exit IP is enriched with.
+type GeoInfo struct {
+	Country string
+	City    string
+	ASN     uint
+	Org     string
+}
+
+// GeoIP looks up country/city/ASN/org data for an IP against a MaxMind
+// GeoLite2 database. City and ASN lookups are best effort: if dbPath points
+// at a City database the ASN fields are simply left empty, and vice versa.
+type GeoIP struct {
+	reader *geoip2.Reader
+}
+
+// OpenGeoIP opens a GeoLite2 database (City or ASN) for enrichment.
+func OpenGeoIP(dbPath string) (*GeoIP, error) {
+	reader, err := geoip2.Open(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoip database: %v", err)
+	}
+	return &GeoIP{reader: reader}, nil
+}
+
+// Close releases the underlying database file.
+func (g *GeoIP) Close() error {
+	return g.reader.Close()
+}
+
+// Lookup returns whatever geo/ASN data the database has for ipStr. Fields
+// that can't be resolved (wrong database type, no match) are left zero.
+func (g *GeoIP) Lookup(ipStr string) GeoInfo {
+	var info GeoInfo
+
+	ip := net.ParseIP(ipStr)
+	if ip == nil {
+		return info
+	}
+
+	if city, err := g.reader.City(ip); err == nil {
+		info.Country = city.Country.IsoCode
+		if name, ok := city.City.Names["en"]; ok {
+			info.City = name
+		}
+	}
+
+	if asn, err := g.reader.ASN(ip); err == nil {
+		info.ASN = asn.AutonomousSystemNumber
+		info.Org = asn.AutonomousSystemOrganization
+	}
+
+	return info
+}
+
+// GeoFilter describes the country/ASN allow- and deny-lists a result set
+// should be filtered against.
+type GeoFilter struct {
+	Countries        []string // allow-list, empty means allow all
+	ExcludeCountries []string
+	ASNs             []uint // allow-list, empty means allow all
+}
+
+// Matches reports whether result passes the filter.
+func (f GeoFilter) Matches(result TestResult) bool {
+	return f.matches(result.Country, result.ASN)
+}
+
+// MatchesStat is Matches for a daemon-tracked ProxyStat, so the same filter
+// can scope both the CLI's one-shot results and the daemon's serving path
+// (handleProxies, handleRandom) without duplicating the allow/deny logic.
+func (f GeoFilter) MatchesStat(stat ProxyStat) bool {
+	return f.matches(stat.Country, stat.ASN)
+}
+
+func (f GeoFilter) matches(country string, asn uint) bool {
+	if len(f.Countries) > 0 && !containsFold(f.Countries, country) {
+		return false
+	}
+	if containsFold(f.ExcludeCountries, country) {
+		return false
+	}
+	if len(f.ASNs) > 0 {
+		matched := false
+		for _, a := range f.ASNs {
+			if a == asn {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveExitIP returns the IP to run geo enrichment against: the
+// classification exit IP if one was found, otherwise the proxy's own host if
+// it's a literal IP, otherwise its first resolved address.
+func resolveExitIP(result TestResult, host string) string {
+	if result.ExitIP != "" {
+		return result.ExitIP
+	}
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	if ips, err := net.LookupIP(host); err == nil {
+		for _, ip := range ips {
+			return ip.String()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package proxypool
+
+import "testing"
+
+func TestGeoFilterMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter GeoFilter
+		result TestResult
+		want   bool
+	}{
+		{
+			name:   "empty filter matches everything",
+			filter: GeoFilter{},
+			result: TestResult{Country: "US", ASN: 15169},
+			want:   true,
+		},
+		{
+			name:   "country allow-list matches",
+			filter: GeoFilter{Countries: []string{"us", "de"}},
+			result: TestResult{Country: "US"},
+			want:   true,
+		},
+		{
+			name:   "country allow-list rejects",
+			filter: GeoFilter{Countries: []string{"de", "jp"}},
+			result: TestResult{Country: "US"},
+			want:   false,
+		},
+		{
+			name:   "country deny-list rejects",
+			filter: GeoFilter{ExcludeCountries: []string{"us"}},
+			result: TestResult{Country: "US"},
+			want:   false,
+		},
+		{
+			name:   "country deny-list is case-insensitive",
+			filter: GeoFilter{ExcludeCountries: []string{"US"}},
+			result: TestResult{Country: "us"},
+			want:   false,
+		},
+		{
+			name:   "ASN allow-list matches",
+			filter: GeoFilter{ASNs: []uint{15169, 8075}},
+			result: TestResult{ASN: 8075},
+			want:   true,
+		},
+		{
+			name:   "ASN allow-list rejects",
+			filter: GeoFilter{ASNs: []uint{15169}},
+			result: TestResult{ASN: 8075},
+			want:   false,
+		},
+		{
+			name:   "country matches but ASN doesn't",
+			filter: GeoFilter{Countries: []string{"US"}, ASNs: []uint{15169}},
+			result: TestResult{Country: "US", ASN: 8075},
+			want:   false,
+		},
+		{
+			name:   "deny-list on empty country never matches",
+			filter: GeoFilter{ExcludeCountries: []string{"US"}},
+			result: TestResult{Country: ""},
+			want:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Matches(tt.result); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+			stat := ProxyStat{Country: tt.result.Country, ASN: tt.result.ASN}
+			if got := tt.filter.MatchesStat(stat); got != tt.want {
+				t.Errorf("MatchesStat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package proxypool
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ProxyStat holds the rolling health stats the daemon tracks for one proxy.
+type ProxyStat struct {
+	Proxy               string
+	ProxyType           string
+	Successes           int
+	Failures            int
+	ConsecutiveFailures int
+	EWMALatency         time.Duration
+	LastChecked         time.Time
+	LastError           string
+	TotalBytes          int64
+	Evicted             bool
+	EvictedAt           time.Time
+	Country             string
+	City                string
+	ASN                 uint
+	Org                 string
+}
+
+// SuccessRate returns the fraction of checks that succeeded, or 0 if the
+// proxy has never been checked.
+func (s ProxyStat) SuccessRate() float64 {
+	total := s.Successes + s.Failures
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Successes) / float64(total)
+}
+
+// ewmaAlpha weights how quickly EWMALatency reacts to a new sample.
+const ewmaAlpha = 0.3
+
+// PoolConfig configures a Pool's rechecking behavior.
+type PoolConfig struct {
+	Proxies    []string
+	Interval   time.Duration
+	Threads    int
+	EvictAfter int // consecutive failures before eviction
+	Cooldown   time.Duration
+	Test       Config
+
+	// GeoActive and GeoFilter scope Working (and therefore the HTTP API's
+	// /proxies and /proxies/random) to proxies matching the country/ASN
+	// filter, mirroring the CLI's -country/-exclude-country/-asn flags.
+	// Every proxy is still rechecked regardless of GeoFilter; only what's
+	// served is scoped, the same split the CLI makes between testing and
+	// filterByGeo.
+	GeoActive bool
+	GeoFilter GeoFilter
+}
+
+// Pool maintains a live, continuously rechecked set of proxies. It is safe
+// for concurrent use.
+type Pool struct {
+	cfg   PoolConfig
+	mu    sync.RWMutex
+	order []string
+	stats map[string]*ProxyStat
+}
+
+// NewPool creates a Pool that will track the proxies in cfg.Proxies. Call
+// Run to start the recheck loop.
+func NewPool(cfg PoolConfig) *Pool {
+	p := &Pool{
+		cfg:   cfg,
+		order: append([]string(nil), cfg.Proxies...),
+		stats: make(map[string]*ProxyStat, len(cfg.Proxies)),
+	}
+	for _, proxyStr := range cfg.Proxies {
+		p.stats[proxyStr] = &ProxyStat{Proxy: proxyStr}
+	}
+	return p
+}
+
+// Run rechecks the pool immediately, then on every cfg.Interval tick, until
+// ctx is canceled.
+func (p *Pool) Run(ctx context.Context) {
+	p.recheck()
+
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.recheck()
+		}
+	}
+}
+
+// recheck tests every proxy that isn't currently in its post-eviction
+// cooldown window and folds the results into each proxy's rolling stats.
+func (p *Pool) recheck() {
+	candidates := p.candidates()
+	if len(candidates) == 0 {
+		return
+	}
+
+	results := TestProxies(candidates, p.cfg.Threads, p.cfg.Test, nil)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, r := range results {
+		p.applyResult(r)
+	}
+}
+
+func (p *Pool) candidates() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]string, 0, len(p.order))
+	for _, proxyStr := range p.order {
+		s := p.stats[proxyStr]
+		if s.Evicted && time.Since(s.EvictedAt) < p.cfg.Cooldown {
+			continue
+		}
+		out = append(out, proxyStr)
+	}
+	return out
+}
+
+// applyResult updates a single proxy's rolling stats. Callers must hold p.mu.
+func (p *Pool) applyResult(r TestResult) {
+	s, ok := p.stats[r.Proxy]
+	if !ok {
+		s = &ProxyStat{Proxy: r.Proxy}
+		p.stats[r.Proxy] = s
+		p.order = append(p.order, r.Proxy)
+	}
+
+	s.ProxyType = r.ProxyType
+	s.LastChecked = time.Now()
+
+	if r.Success {
+		s.Successes++
+		s.ConsecutiveFailures = 0
+		s.Evicted = false
+		s.LastError = ""
+		s.TotalBytes += r.BytesRead
+		if r.Country != "" {
+			s.Country = r.Country
+			s.City = r.City
+			s.ASN = r.ASN
+			s.Org = r.Org
+		}
+		if s.EWMALatency == 0 {
+			s.EWMALatency = r.Latency
+		} else {
+			s.EWMALatency = time.Duration(ewmaAlpha*float64(r.Latency) + (1-ewmaAlpha)*float64(s.EWMALatency))
+		}
+	} else {
+		s.Failures++
+		s.ConsecutiveFailures++
+		s.LastError = r.Error
+		if p.cfg.EvictAfter > 0 && s.ConsecutiveFailures >= p.cfg.EvictAfter && !s.Evicted {
+			s.Evicted = true
+			s.EvictedAt = time.Now()
+		}
+	}
+}
+
+// Snapshot returns a copy of every tracked proxy's stats, ordered as configured.
+func (p *Pool) Snapshot() []ProxyStat {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	out := make([]ProxyStat, 0, len(p.order))
+	for _, proxyStr := range p.order {
+		out = append(out, *p.stats[proxyStr])
+	}
+	return out
+}
+
+// Working returns the proxies that are not evicted, have at least one
+// recorded success, and (if a geo filter is configured) match it, sorted by
+// lowest EWMA latency first.
+func (p *Pool) Working() []ProxyStat {
+	all := p.Snapshot()
+
+	out := make([]ProxyStat, 0, len(all))
+	for _, s := range all {
+		if !s.Evicted && s.Successes > 0 && (!p.cfg.GeoActive || p.cfg.GeoFilter.MatchesStat(s)) {
+			out = append(out, s)
+		}
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].EWMALatency < out[j].EWMALatency })
+	return out
+}
+
+// Random returns a uniformly random working proxy, or false if the pool has
+// none.
+func (p *Pool) Random() (ProxyStat, bool) {
+	working := p.Working()
+	if len(working) == 0 {
+		return ProxyStat{}, false
+	}
+	return working[rand.Intn(len(working))], true
+}
@@ -0,0 +1,38 @@
+package proxypool
+
+import "testing"
+
+func TestPoolWorkingAppliesGeoFilter(t *testing.T) {
+	p := NewPool(PoolConfig{
+		Proxies:   []string{"1.2.3.4:1080", "5.6.7.8:1080"},
+		GeoActive: true,
+		GeoFilter: GeoFilter{Countries: []string{"US"}},
+	})
+
+	p.mu.Lock()
+	p.applyResult(TestResult{Proxy: "1.2.3.4:1080", Success: true, Country: "US"})
+	p.applyResult(TestResult{Proxy: "5.6.7.8:1080", Success: true, Country: "DE"})
+	p.mu.Unlock()
+
+	working := p.Working()
+	if len(working) != 1 || working[0].Proxy != "1.2.3.4:1080" {
+		t.Fatalf("Working() = %+v, want only 1.2.3.4:1080 (US)", working)
+	}
+}
+
+func TestPoolApplyResultStoresGeoFields(t *testing.T) {
+	p := NewPool(PoolConfig{Proxies: []string{"1.2.3.4:1080"}})
+
+	p.mu.Lock()
+	p.applyResult(TestResult{Proxy: "1.2.3.4:1080", Success: true, Country: "US", City: "Ashburn", ASN: 15169, Org: "Google"})
+	p.mu.Unlock()
+
+	snap := p.Snapshot()
+	if len(snap) != 1 {
+		t.Fatalf("Snapshot() returned %d entries, want 1", len(snap))
+	}
+	got := snap[0]
+	if got.Country != "US" || got.City != "Ashburn" || got.ASN != 15169 || got.Org != "Google" {
+		t.Errorf("stat geo fields = %+v, want Country=US City=Ashburn ASN=15169 Org=Google", got)
+	}
+}
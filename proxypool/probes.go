@@ -0,0 +1,318 @@
+package proxypool
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultHTTPProbeURL  = "http://neverssl.com/"
+	defaultHTTPSProbeURL = "https://kodelatte.com/"
+	ipv6ProbeTarget      = "ipv6.google.com:443"
+	dnsProbeServer       = "8.8.8.8:53"
+	dnsProbeDomain       = "example.com"
+)
+
+// runProbe dispatches a single probe kind ("http", "https", "udp", "ipv6",
+// or "tcp:host:port") against proxy.
+func runProbe(info *ProxyInfo, cfg Config, kind string) ProbeResult {
+	switch {
+	case kind == "http" || kind == "https":
+		return probeHTTPGet(info, cfg, probeURLFor(kind, cfg))
+	case kind == "udp":
+		return probeUDPAssociate(info, cfg.Timeout)
+	case kind == "ipv6":
+		return probeTCPConnect(info, cfg.Timeout, ipv6ProbeTarget)
+	case strings.HasPrefix(kind, "tcp:"):
+		target := strings.TrimPrefix(kind, "tcp:")
+		return probeTCPConnect(info, cfg.Timeout, target)
+	default:
+		return ProbeResult{Error: fmt.Sprintf("unknown test kind %q", kind)}
+	}
+}
+
+// probeURLFor picks the GET target for an http/https probe.
+func probeURLFor(kind string, cfg Config) string {
+	if kind == "https" {
+		return defaultHTTPSProbeURL
+	}
+	return defaultHTTPProbeURL
+}
+
+// probeHTTPGet fetches testURL through the proxy and reports success for any
+// 2xx/3xx response.
+func probeHTTPGet(info *ProxyInfo, cfg Config, testURL string) ProbeResult {
+	start := time.Now()
+
+	client, err := buildProxyClient(info, cfg.Timeout)
+	if err != nil {
+		return ProbeResult{Error: err.Error()}
+	}
+
+	req, err := http.NewRequest("GET", testURL, nil)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("failed to create request: %v", err)}
+	}
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 SOPAN/1.0")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("request failed: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	n, err := io.Copy(io.Discard, resp.Body)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("failed to read response: %v", err)}
+	}
+
+	success := resp.StatusCode >= 200 && resp.StatusCode < 400
+	pr := ProbeResult{
+		Success:    success,
+		Latency:    time.Since(start),
+		BytesRead:  n,
+		HTTPStatus: resp.StatusCode,
+	}
+	if !success {
+		pr.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+	}
+	return pr
+}
+
+// probeTCPConnect opens a raw TCP connection to target through the proxy and
+// reads whatever banner (if any) the target sends within the timeout. Used
+// for both the generic "tcp:host:port" probe and the "ipv6" probe.
+func probeTCPConnect(info *ProxyInfo, timeout time.Duration, target string) ProbeResult {
+	start := time.Now()
+
+	conn, err := dialViaProxy(info, timeout, target)
+	if err != nil {
+		return ProbeResult{Error: err.Error()}
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 512)
+	n, _ := conn.Read(buf) // best effort banner read; many services don't greet first
+
+	return ProbeResult{Success: true, Latency: time.Since(start), BytesRead: int64(n)}
+}
+
+// dialViaProxy opens a raw TCP connection to target through proxy info,
+// regardless of scheme: socks4/socks4a/socks5/ssh use their Dialer, http/https
+// use a manual CONNECT tunnel.
+func dialViaProxy(info *ProxyInfo, timeout time.Duration, target string) (net.Conn, error) {
+	if info.Scheme == "http" || info.Scheme == "https" {
+		return connectTunnel(info, timeout, target)
+	}
+	dialer, err := buildDialer(info, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dialer: %v", err)
+	}
+	return dialer.Dial("tcp", target)
+}
+
+// connectTunnel performs an HTTP CONNECT handshake through an http/https
+// proxy to open a raw tunnel to target.
+func connectTunnel(info *ProxyInfo, timeout time.Duration, target string) (net.Conn, error) {
+	proxyAddr := net.JoinHostPort(info.Host, info.Port)
+
+	var conn net.Conn
+	var err error
+	if info.Scheme == "https" {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", proxyAddr, &tls.Config{})
+	} else {
+		conn, err = net.DialTimeout("tcp", proxyAddr, timeout)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial proxy: %v", err)
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", target, target)
+	if info.Username != "" {
+		creds := base64.StdEncoding.EncodeToString([]byte(info.Username + ":" + info.Password))
+		req += fmt.Sprintf("Proxy-Authorization: Basic %s\r\n", creds)
+	}
+	req += "\r\n"
+
+	if _, err := conn.Write([]byte(req)); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT: %v", err)
+	}
+
+	br := bufio.NewReader(conn)
+	statusLine, err := br.ReadString('\n')
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response: %v", err)
+	}
+	if !strings.Contains(statusLine, "200") {
+		conn.Close()
+		return nil, fmt.Errorf("proxy CONNECT failed: %s", strings.TrimSpace(statusLine))
+	}
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to read CONNECT headers: %v", err)
+		}
+		if line == "\r\n" || line == "\n" {
+			break
+		}
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// probeUDPAssociate exercises a SOCKS5 UDP ASSOCIATE by sending a DNS query
+// for dnsProbeDomain to dnsProbeServer through the relay and checking for a
+// response. Only socks5 proxies support UDP ASSOCIATE.
+func probeUDPAssociate(info *ProxyInfo, timeout time.Duration) ProbeResult {
+	if info.Scheme != "socks5" {
+		return ProbeResult{Error: "UDP ASSOCIATE requires a socks5 proxy"}
+	}
+
+	start := time.Now()
+
+	proxyAddr := net.JoinHostPort(info.Host, info.Port)
+	conn, err := net.DialTimeout("tcp", proxyAddr, timeout)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("failed to dial proxy: %v", err)}
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	methods := []byte{0x00}
+	if info.Username != "" {
+		methods = []byte{0x02}
+	}
+	if _, err := conn.Write(append([]byte{0x05, byte(len(methods))}, methods...)); err != nil {
+		return ProbeResult{Error: fmt.Sprintf("handshake failed: %v", err)}
+	}
+
+	greeting := make([]byte, 2)
+	if _, err := io.ReadFull(conn, greeting); err != nil {
+		return ProbeResult{Error: fmt.Sprintf("handshake failed: %v", err)}
+	}
+	if greeting[0] != 0x05 {
+		return ProbeResult{Error: "invalid socks5 version in handshake"}
+	}
+
+	switch greeting[1] {
+	case 0x00:
+		// no auth required
+	case 0x02:
+		auth := append([]byte{0x01, byte(len(info.Username))}, []byte(info.Username)...)
+		auth = append(auth, byte(len(info.Password)))
+		auth = append(auth, []byte(info.Password)...)
+		if _, err := conn.Write(auth); err != nil {
+			return ProbeResult{Error: fmt.Sprintf("auth failed: %v", err)}
+		}
+		authResp := make([]byte, 2)
+		if _, err := io.ReadFull(conn, authResp); err != nil {
+			return ProbeResult{Error: fmt.Sprintf("auth failed: %v", err)}
+		}
+		if authResp[1] != 0x00 {
+			return ProbeResult{Error: "socks5 authentication rejected"}
+		}
+	default:
+		return ProbeResult{Error: fmt.Sprintf("server rejected all auth methods, code %#x", greeting[1])}
+	}
+
+	if _, err := conn.Write([]byte{0x05, 0x03, 0x00, 0x01, 0, 0, 0, 0, 0, 0}); err != nil {
+		return ProbeResult{Error: fmt.Sprintf("UDP ASSOCIATE request failed: %v", err)}
+	}
+
+	reply := make([]byte, 10)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return ProbeResult{Error: fmt.Sprintf("UDP ASSOCIATE reply failed: %v", err)}
+	}
+	if reply[1] != 0x00 {
+		return ProbeResult{Error: fmt.Sprintf("UDP ASSOCIATE rejected, code %#x", reply[1])}
+	}
+
+	relayIP := net.IP(reply[4:8])
+	relayPort := int(reply[8])<<8 | int(reply[9])
+	relayHost := relayIP.String()
+	if relayIP.Equal(net.IPv4zero) {
+		// 0.0.0.0 means "same host as the TCP control connection"
+		relayHost = info.Host
+	}
+	relayAddr := net.JoinHostPort(relayHost, strconv.Itoa(relayPort))
+
+	udpConn, err := net.DialTimeout("udp", relayAddr, timeout)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("failed to dial UDP relay: %v", err)}
+	}
+	defer udpConn.Close()
+	udpConn.SetDeadline(time.Now().Add(timeout))
+
+	packet, err := socks5UDPPacket(dnsProbeServer, buildDNSQuery(dnsProbeDomain))
+	if err != nil {
+		return ProbeResult{Error: err.Error()}
+	}
+	if _, err := udpConn.Write(packet); err != nil {
+		return ProbeResult{Error: fmt.Sprintf("failed to send UDP datagram: %v", err)}
+	}
+
+	buf := make([]byte, 512)
+	n, err := udpConn.Read(buf)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("no UDP response: %v", err)}
+	}
+
+	return ProbeResult{Success: true, Latency: time.Since(start), BytesRead: int64(n)}
+}
+
+// socks5UDPPacket wraps payload in a SOCKS5 UDP request header (RFC 1928
+// section 7) addressed to dest ("host:port").
+func socks5UDPPacket(dest string, payload []byte) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(dest)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UDP destination: %v", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid UDP destination port: %v", err)
+	}
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("UDP destination must be an IPv4 address, got %s", host)
+	}
+
+	packet := []byte{0x00, 0x00, 0x00, 0x01}
+	packet = append(packet, ip...)
+	packet = append(packet, byte(port>>8), byte(port))
+	packet = append(packet, payload...)
+	return packet, nil
+}
+
+// buildDNSQuery builds a minimal DNS "A" query for domain.
+func buildDNSQuery(domain string) []byte {
+	q := []byte{
+		0x12, 0x34, // transaction ID
+		0x01, 0x00, // flags: standard query, recursion desired
+		0x00, 0x01, // QDCOUNT = 1
+		0x00, 0x00, // ANCOUNT
+		0x00, 0x00, // NSCOUNT
+		0x00, 0x00, // ARCOUNT
+	}
+	for _, label := range strings.Split(domain, ".") {
+		q = append(q, byte(len(label)))
+		q = append(q, []byte(label)...)
+	}
+	q = append(q, 0x00)       // root label
+	q = append(q, 0x00, 0x01) // QTYPE = A
+	q = append(q, 0x00, 0x01) // QCLASS = IN
+	return q
+}
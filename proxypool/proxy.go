@@ -0,0 +1,550 @@
+// Package proxypool implements proxy parsing, dialing, and testing shared by
+// sopan's single-shot CLI mode and its long-running daemon mode.
+package proxypool
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/net/proxy"
+)
+
+// ProxyInfo holds proxy connection details
+type ProxyInfo struct {
+	Scheme   string
+	Host     string
+	Port     string
+	Username string
+	Password string
+	KeyFile  string
+	Raw      string
+}
+
+// ProbeResult holds the outcome of a single probe (http, https, tcp:host:port,
+// udp, ipv6) run against a proxy.
+type ProbeResult struct {
+	Success    bool
+	Latency    time.Duration
+	BytesRead  int64
+	HTTPStatus int
+	Error      string
+}
+
+// TestResult holds the result of testing a proxy against every probe in
+// Config.Tests. Error is set when the proxy string couldn't be parsed, or,
+// when no probe succeeded, to the first failing probe's error; per-probe
+// outcomes live in Probes. Success/Latency/BytesRead aggregate across probes (Latency is the
+// fastest successful probe's) so existing single-result consumers (display,
+// CSV/JSON output, the daemon pool) keep working unchanged.
+type TestResult struct {
+	Proxy          string
+	ProxyType      string
+	Host           string
+	Port           string
+	Error          string
+	Probes         map[string]ProbeResult
+	Success        bool
+	Latency        time.Duration
+	BytesRead      int64
+	HTTPStatus     int
+	ExitIP         string
+	AnonymityLevel string
+	LeakedHeaders  []string
+	Country        string
+	City           string
+	ASN            uint
+	Org            string
+}
+
+// Config bundles the settings that apply to every proxy test, gathered from
+// flags (and, for RealIP, a startup probe) so that TestProxy and TestProxies
+// don't have to grow a new positional parameter for every feature.
+type Config struct {
+	Timeout   time.Duration
+	Tests     []string // probe kinds: http, https, tcp:host:port, udp, ipv6
+	Classify  bool
+	JudgeURLs []string
+	RealIP    string
+	GeoIP     *GeoIP
+
+	// MinConcurrency and MaxConcurrency bound the adaptive scheduler used by
+	// TestProxies; MaxConcurrency of 0 falls back to the threads argument
+	// passed to TestProxies, and MinConcurrency of 0 defaults to a quarter
+	// of the max.
+	MinConcurrency int
+	MaxConcurrency int
+
+	// GlobalRate and PerHostRate cap requests/sec across the whole run and
+	// per proxy host respectively, via token buckets. 0 means unlimited.
+	GlobalRate  float64
+	PerHostRate float64
+}
+
+// DefaultTests is used when Config.Tests is empty, matching sopan's original
+// single HTTPS GET behavior.
+var DefaultTests = []string{"https"}
+
+// defaultPortForScheme returns the conventional port for a proxy scheme when
+// the input URI didn't specify one.
+func defaultPortForScheme(scheme string) string {
+	switch scheme {
+	case "http":
+		return "80"
+	case "https":
+		return "443"
+	case "ssh":
+		return "22"
+	default:
+		return "1080"
+	}
+}
+
+var supportedSchemes = map[string]bool{
+	"http":    true,
+	"https":   true,
+	"socks4":  true,
+	"socks4a": true,
+	"socks5":  true,
+	"ssh":     true,
+}
+
+// ParseProxy parses a proxy string into ProxyInfo, detecting the scheme from
+// the URI (http, https, socks4, socks4a, socks5, ssh). A bare host:port with
+// no scheme is treated as socks5 for backwards compatibility.
+func ParseProxy(proxyStr string) (*ProxyInfo, error) {
+	raw := proxyStr
+	if !strings.Contains(proxyStr, "://") {
+		proxyStr = "socks5://" + proxyStr
+	}
+
+	u, err := url.Parse(proxyStr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy format: %v", err)
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if !supportedSchemes[scheme] {
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", scheme)
+	}
+
+	info := &ProxyInfo{
+		Scheme: scheme,
+		Host:   u.Hostname(),
+		Port:   u.Port(),
+		Raw:    raw,
+	}
+
+	if info.Port == "" {
+		info.Port = defaultPortForScheme(scheme)
+	}
+
+	if u.User != nil {
+		info.Username = u.User.Username()
+		info.Password, _ = u.User.Password()
+	}
+	info.KeyFile = u.Query().Get("key")
+
+	if info.Host == "" || info.Port == "" {
+		return nil, fmt.Errorf("invalid proxy format: missing host or port")
+	}
+
+	return info, nil
+}
+
+// Dialer is the common interface implemented by every proxy backend we
+// support. buildDialer picks an implementation based on the parsed scheme;
+// buildProxyClient uses it as the dial function for an http.Transport and
+// dialViaProxy uses it for raw TCP probes.
+type Dialer interface {
+	Dial(network, addr string) (net.Conn, error)
+}
+
+// buildDialer returns the Dialer backend for proxy schemes that tunnel raw
+// TCP connections (socks4, socks4a, socks5, ssh). http and https proxies are
+// handled separately: buildProxyClient uses http.Transport's built-in CONNECT
+// support and dialViaProxy uses connectTunnel.
+func buildDialer(info *ProxyInfo, timeout time.Duration) (Dialer, error) {
+	addr := net.JoinHostPort(info.Host, info.Port)
+
+	switch info.Scheme {
+	case "socks5":
+		var auth *proxy.Auth
+		if info.Username != "" {
+			auth = &proxy.Auth{User: info.Username, Password: info.Password}
+		}
+		d, err := proxy.SOCKS5("tcp", addr, auth, &net.Dialer{Timeout: timeout})
+		if err != nil {
+			return nil, err
+		}
+		// proxy.SOCKS5's Dial is deprecated in favor of DialContext and never
+		// applies a deadline to the handshake itself, only to the initial
+		// TCP connect via the forward Dialer above; wrap it so the whole
+		// dial (connect + handshake) is bounded by timeout, as every other
+		// scheme's dialer is.
+		ctxDialer, ok := d.(interface {
+			DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+		})
+		if !ok {
+			return d, nil
+		}
+		return &timeoutDialer{ctxDialer: ctxDialer, timeout: timeout}, nil
+	case "socks4", "socks4a":
+		return &socks4Dialer{proxyAddr: addr, username: info.Username, socks4a: info.Scheme == "socks4a", timeout: timeout}, nil
+	case "ssh":
+		config, err := sshClientConfig(info, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return &sshDialer{addr: addr, config: config, timeout: timeout}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %s", info.Scheme)
+	}
+}
+
+// timeoutDialer wraps a Dialer whose underlying implementation also exposes
+// DialContext, so a single timeout bounds the entire dial (TCP connect plus
+// proxy handshake) instead of just the connect step.
+type timeoutDialer struct {
+	ctxDialer interface {
+		DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+	}
+	timeout time.Duration
+}
+
+func (d *timeoutDialer) Dial(network, addr string) (net.Conn, error) {
+	ctx := context.Background()
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	return d.ctxDialer.DialContext(ctx, network, addr)
+}
+
+// socks4Dialer implements Dialer for SOCKS4 and SOCKS4a proxies.
+type socks4Dialer struct {
+	proxyAddr string
+	username  string
+	socks4a   bool
+	timeout   time.Duration
+}
+
+func (d *socks4Dialer) Dial(network, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("socks4: invalid target address: %v", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, fmt.Errorf("socks4: invalid target port: %v", err)
+	}
+
+	conn, err := net.DialTimeout("tcp", d.proxyAddr, d.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("socks4: failed to dial proxy: %v", err)
+	}
+	if d.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(d.timeout))
+	}
+
+	req := []byte{0x04, 0x01, byte(port >> 8), byte(port)}
+
+	if d.socks4a {
+		// SOCKS4a: signal hostname resolution by the proxy with IP 0.0.0.x (x != 0)
+		req = append(req, 0, 0, 0, 1)
+		req = append(req, []byte(d.username)...)
+		req = append(req, 0)
+		req = append(req, []byte(host)...)
+		req = append(req, 0)
+	} else {
+		ip, err := resolveIPv4(host)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("socks4: failed to resolve host: %v", err)
+		}
+		req = append(req, ip...)
+		req = append(req, []byte(d.username)...)
+		req = append(req, 0)
+	}
+
+	if _, err := conn.Write(req); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("socks4: failed to send request: %v", err)
+	}
+
+	resp := make([]byte, 8)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("socks4: failed to read response: %v", err)
+	}
+
+	if resp[0] != 0x00 || resp[1] != 0x5a {
+		conn.Close()
+		return nil, fmt.Errorf("socks4: connect rejected, code %#x", resp[1])
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// resolveIPv4 resolves host to an IPv4 address for the SOCKS4 request, which
+// has no facility for proxy-side DNS resolution (that's what SOCKS4a is for).
+func resolveIPv4(host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4, nil
+		}
+		return nil, fmt.Errorf("%s is not an IPv4 address", host)
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4, nil
+		}
+	}
+	return nil, fmt.Errorf("no IPv4 address found for %s", host)
+}
+
+// sshDialer implements Dialer by tunneling connections through an SSH
+// client's "direct-tcpip" channel.
+type sshDialer struct {
+	addr    string
+	config  *ssh.ClientConfig
+	timeout time.Duration
+}
+
+func (d *sshDialer) Dial(network, addr string) (net.Conn, error) {
+	conn, err := net.DialTimeout("tcp", d.addr, d.timeout)
+	if err != nil {
+		return nil, fmt.Errorf("ssh: failed to dial proxy: %v", err)
+	}
+	// ssh.Dial's DialTimeout only bounds the TCP connect; set a deadline
+	// across the handshake and the direct-tcpip channel open below too, the
+	// same way connectTunnel bounds an http/https CONNECT handshake.
+	if d.timeout > 0 {
+		conn.SetDeadline(time.Now().Add(d.timeout))
+	}
+
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, d.addr, d.config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh: failed to handshake with proxy: %v", err)
+	}
+	client := ssh.NewClient(sshConn, chans, reqs)
+
+	tunnel, err := client.Dial(network, addr)
+	if err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ssh: failed to dial target: %v", err)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return &sshTunnelConn{Conn: tunnel, client: client}, nil
+}
+
+// sshTunnelConn closes the underlying SSH client alongside the tunneled
+// channel, so every Dial doesn't leak a whole SSH session (connection plus
+// client-side goroutines) once the caller is done with it.
+type sshTunnelConn struct {
+	net.Conn
+	client *ssh.Client
+}
+
+func (c *sshTunnelConn) Close() error {
+	connErr := c.Conn.Close()
+	clientErr := c.client.Close()
+	if connErr != nil {
+		return connErr
+	}
+	return clientErr
+}
+
+// sshClientConfig builds the ssh.ClientConfig for an ssh:// proxy, using key
+// file authentication if a "key" query parameter was given, falling back to
+// password authentication.
+func sshClientConfig(info *ProxyInfo, timeout time.Duration) (*ssh.ClientConfig, error) {
+	var authMethods []ssh.AuthMethod
+
+	if info.KeyFile != "" {
+		key, err := os.ReadFile(info.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %v", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key file: %v", err)
+		}
+		authMethods = append(authMethods, ssh.PublicKeys(signer))
+	} else {
+		authMethods = append(authMethods, ssh.Password(info.Password))
+	}
+
+	return &ssh.ClientConfig{
+		User:            info.Username,
+		Auth:            authMethods,
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         timeout,
+	}, nil
+}
+
+// httpProxyTransport builds an http.Transport that routes requests through
+// an http:// or https:// proxy using CONNECT (handled automatically by the
+// transport for https targets).
+func httpProxyTransport(info *ProxyInfo) *http.Transport {
+	proxyURL := &url.URL{
+		Scheme: info.Scheme,
+		Host:   net.JoinHostPort(info.Host, info.Port),
+	}
+	if info.Username != "" {
+		proxyURL.User = url.UserPassword(info.Username, info.Password)
+	}
+
+	return &http.Transport{
+		Proxy:             http.ProxyURL(proxyURL),
+		TLSClientConfig:   &tls.Config{InsecureSkipVerify: false},
+		DisableKeepAlives: true,
+	}
+}
+
+// TestProxy runs every probe in cfg.Tests (or DefaultTests) against a single
+// proxy, then optionally classifies its anonymity level and enriches it with
+// GeoIP data if any probe succeeded.
+func TestProxy(proxyStr string, cfg Config) TestResult {
+	result := TestResult{Proxy: proxyStr, Probes: map[string]ProbeResult{}}
+
+	proxyInfo, err := ParseProxy(proxyStr)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.ProxyType = proxyInfo.Scheme
+	result.Host = proxyInfo.Host
+	result.Port = proxyInfo.Port
+
+	tests := cfg.Tests
+	if len(tests) == 0 {
+		tests = DefaultTests
+	}
+
+	for _, kind := range tests {
+		pr := runProbe(proxyInfo, cfg, kind)
+		result.Probes[kind] = pr
+		result.BytesRead += pr.BytesRead
+
+		if !pr.Success {
+			continue
+		}
+		result.Success = true
+		if pr.HTTPStatus != 0 {
+			result.HTTPStatus = pr.HTTPStatus
+		}
+		if result.Latency == 0 || pr.Latency < result.Latency {
+			result.Latency = pr.Latency
+		}
+	}
+
+	if !result.Success {
+		for _, kind := range tests {
+			if pr := result.Probes[kind]; pr.Error != "" {
+				result.Error = pr.Error
+				break
+			}
+		}
+		return result
+	}
+
+	if cfg.Classify || cfg.GeoIP != nil {
+		client, err := buildProxyClient(proxyInfo, cfg.Timeout)
+		if err == nil {
+			if cfg.Classify {
+				exitIP, level, leaked, err := classifyProxy(client, cfg.JudgeURLs, cfg.RealIP)
+				if err != nil {
+					result.AnonymityLevel = "unknown"
+				} else {
+					result.ExitIP = exitIP
+					result.AnonymityLevel = level
+					result.LeakedHeaders = leaked
+				}
+			}
+			if cfg.GeoIP != nil {
+				if ip := resolveExitIP(result, proxyInfo.Host); ip != "" {
+					geo := cfg.GeoIP.Lookup(ip)
+					result.Country = geo.Country
+					result.City = geo.City
+					result.ASN = geo.ASN
+					result.Org = geo.Org
+				}
+			}
+		}
+	}
+
+	return result
+}
+
+// buildProxyClient builds an http.Client that routes through info, for use
+// by the classification and GeoIP stages regardless of which probes ran.
+func buildProxyClient(info *ProxyInfo, timeout time.Duration) (*http.Client, error) {
+	var transport *http.Transport
+	if info.Scheme == "http" || info.Scheme == "https" {
+		transport = httpProxyTransport(info)
+	} else {
+		dialer, err := buildDialer(info, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create dialer: %v", err)
+		}
+		transport = &http.Transport{
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				return dialer.Dial(network, addr)
+			},
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: false},
+			DisableKeepAlives: true,
+		}
+	}
+
+	return &http.Client{
+		Transport: transport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}, nil
+}
+
+// TestProxies tests multiple proxies concurrently under an adaptive AIMD
+// concurrency scheduler (see scheduleAdaptive) instead of a fixed worker
+// pool, so a run backs off automatically when proxies start timing out and
+// ramps back up once they recover. threads caps concurrency when
+// cfg.MaxConcurrency isn't set. If onResult is non-nil, it is invoked with
+// each TestResult as soon as that proxy's test finishes, which lets callers
+// stream results (e.g. -format jsonl) instead of waiting for the whole batch
+// to complete. onResult calls are serialized (never concurrent with one
+// another), so callers may safely keep unsynchronized state such as running
+// counters or a shared encoder inside it.
+func TestProxies(proxies []string, threads int, cfg Config, onResult func(TestResult)) []TestResult {
+	results, _ := TestProxiesWithStats(proxies, threads, cfg, onResult)
+	return results
+}
+
+// TestProxiesWithStats is TestProxies but also returns the scheduler's live
+// Stats, for callers that want to report in-flight/rejected counts (e.g.
+// -verbose).
+func TestProxiesWithStats(proxies []string, threads int, cfg Config, onResult func(TestResult)) ([]TestResult, *Stats) {
+	if cfg.MaxConcurrency <= 0 {
+		cfg.MaxConcurrency = threads
+	}
+	return scheduleAdaptive(proxies, cfg, onResult)
+}
@@ -0,0 +1,273 @@
+package proxypool
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// aimdWindow is how many recent outcomes the adaptive scheduler looks at
+// when deciding whether to grow or shrink its concurrency target.
+const aimdWindow = 20
+
+// aimdFailThreshold is the failure rate within the window above which the
+// scheduler backs off (multiplicative decrease).
+const aimdFailThreshold = 0.3
+
+// adaptiveScheduler tracks a moving target for how many proxy tests should
+// run at once: it grows by one after a full window of sustained success
+// (additive increase) and halves when the failure rate in the window crosses
+// aimdFailThreshold (multiplicative decrease), the classic AIMD shape used
+// for network congestion control.
+type adaptiveScheduler struct {
+	mu            sync.Mutex
+	target        int
+	min           int
+	max           int
+	window        []bool
+	successStreak int
+}
+
+func newAdaptiveScheduler(min, max int) *adaptiveScheduler {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	return &adaptiveScheduler{target: min, min: min, max: max}
+}
+
+// Target returns the current concurrency target.
+func (a *adaptiveScheduler) Target() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.target
+}
+
+// Report records one proxy test's outcome and adjusts the target.
+func (a *adaptiveScheduler) Report(success bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.window = append(a.window, success)
+	if len(a.window) > aimdWindow {
+		a.window = a.window[1:]
+	}
+	if len(a.window) < aimdWindow {
+		return
+	}
+
+	failures := 0
+	for _, ok := range a.window {
+		if !ok {
+			failures++
+		}
+	}
+
+	if float64(failures)/float64(len(a.window)) > aimdFailThreshold {
+		a.target = maxInt(a.min, a.target/2)
+		a.window = a.window[:0]
+		a.successStreak = 0
+		return
+	}
+
+	if success {
+		a.successStreak++
+		if a.successStreak >= aimdWindow {
+			a.target = minInt(a.max, a.target+1)
+			a.successStreak = 0
+		}
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// Stats exposes the adaptive scheduler and rate limiters' live state so
+// callers can report progress (e.g. -verbose).
+type Stats struct {
+	inFlight int32
+	peak     int32
+	rejected int64
+	target   int32
+}
+
+// InFlight returns the number of proxy tests currently running.
+func (s *Stats) InFlight() int {
+	return int(atomic.LoadInt32(&s.inFlight))
+}
+
+// Peak returns the highest in-flight count seen so far.
+func (s *Stats) Peak() int {
+	return int(atomic.LoadInt32(&s.peak))
+}
+
+// Rejected returns how many times a request had to wait for a rate-limiter
+// token rather than being admitted immediately, counted once per limiter
+// (global, per-host) that made it wait.
+func (s *Stats) Rejected() int64 {
+	return atomic.LoadInt64(&s.rejected)
+}
+
+// Target returns the adaptive scheduler's current concurrency target.
+func (s *Stats) Target() int {
+	return int(atomic.LoadInt32(&s.target))
+}
+
+func (s *Stats) enter() {
+	n := atomic.AddInt32(&s.inFlight, 1)
+	for {
+		peak := atomic.LoadInt32(&s.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&s.peak, peak, n) {
+			break
+		}
+	}
+}
+
+func (s *Stats) leave() { atomic.AddInt32(&s.inFlight, -1) }
+
+// hostLimiters lazily creates and caches a per-host rate.Limiter.
+type hostLimiters struct {
+	mu       sync.Mutex
+	rate     float64
+	limiters map[string]*rate.Limiter
+}
+
+func newHostLimiters(ratePerSec float64) *hostLimiters {
+	if ratePerSec <= 0 {
+		return nil
+	}
+	return &hostLimiters{rate: ratePerSec, limiters: make(map[string]*rate.Limiter)}
+}
+
+func (h *hostLimiters) get(host string) *rate.Limiter {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	l, ok := h.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(h.rate), 1)
+		h.limiters[host] = l
+	}
+	return l
+}
+
+// waitForRate blocks until both the global and per-host limiters (whichever
+// are configured) admit a request, counting an immediate rejection whenever
+// a token wasn't already available.
+func waitForRate(ctx context.Context, global *rate.Limiter, host *rate.Limiter, stats *Stats) {
+	for _, l := range []*rate.Limiter{global, host} {
+		if l == nil {
+			continue
+		}
+		if !l.Allow() {
+			atomic.AddInt64(&stats.rejected, 1)
+			l.Wait(ctx)
+		}
+	}
+}
+
+// scheduleAdaptive runs TestProxy for each proxy under an AIMD concurrency
+// target and optional global/per-host token-bucket rate limits, calling
+// onResult (if non-nil) as each test completes.
+func scheduleAdaptive(proxies []string, cfg Config, onResult func(TestResult)) ([]TestResult, *Stats) {
+	minC := cfg.MinConcurrency
+	maxC := cfg.MaxConcurrency
+	if maxC <= 0 {
+		maxC = 10
+	}
+	if minC <= 0 {
+		minC = maxInt(1, maxC/4)
+	}
+
+	sched := newAdaptiveScheduler(minC, maxC)
+	stats := &Stats{}
+	global := newLimiter(cfg.GlobalRate)
+	perHost := newHostLimiters(cfg.PerHostRate)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var results []TestResult
+
+	// sem caps the number of live workers at the hard maxC ceiling; the
+	// loop below additionally holds admission to sched's current target.
+	sem := make(chan struct{}, maxC)
+	proxyChan := make(chan string)
+
+	go func() {
+		defer close(proxyChan)
+		for _, p := range proxies {
+			proxyChan <- p
+		}
+	}()
+
+	for proxyStr := range proxyChan {
+		// Throttle admission to the scheduler's current target rather than
+		// the hard maxC ceiling: poll on a short interval until the in-flight
+		// count is below the target, then block for a sem permit.
+		for {
+			atomic.StoreInt32(&stats.target, int32(sched.Target()))
+			if stats.InFlight() < sched.Target() {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(proxyStr string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			info, err := ParseProxy(proxyStr)
+			var hostLimiter *rate.Limiter
+			if err == nil {
+				hostLimiter = perHost.get(info.Host)
+			}
+			waitForRate(ctx, global, hostLimiter, stats)
+
+			stats.enter()
+			result := TestProxy(proxyStr, cfg)
+			stats.leave()
+
+			sched.Report(result.Success)
+
+			mu.Lock()
+			results = append(results, result)
+			if onResult != nil {
+				// Held under mu so onResult is never called concurrently
+				// with itself; see TestProxies' doc comment.
+				onResult(result)
+			}
+			mu.Unlock()
+		}(proxyStr)
+	}
+
+	wg.Wait()
+	return results, stats
+}
+
+func newLimiter(ratePerSec float64) *rate.Limiter {
+	if ratePerSec <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(ratePerSec), 1)
+}
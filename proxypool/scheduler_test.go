@@ -0,0 +1,93 @@
+package proxypool
+
+import "testing"
+
+func TestAdaptiveSchedulerReport(t *testing.T) {
+	t.Run("grows by one after a full window of sustained success", func(t *testing.T) {
+		sched := newAdaptiveScheduler(2, 10)
+		// The window (aimdWindow calls) has to fill before Report starts
+		// tracking successStreak; the filling call counts as the first of
+		// aimdWindow back-to-back successes, so growth lands on call
+		// 2*aimdWindow-1 and the last call only restarts the streak.
+		for i := 0; i < aimdWindow*2; i++ {
+			sched.Report(true)
+		}
+		if got := sched.Target(); got != 3 {
+			t.Errorf("Target() = %d, want 3", got)
+		}
+	})
+
+	t.Run("does not exceed max", func(t *testing.T) {
+		sched := newAdaptiveScheduler(2, 3)
+		for i := 0; i < aimdWindow*3; i++ {
+			sched.Report(true)
+		}
+		if got := sched.Target(); got != 3 {
+			t.Errorf("Target() = %d, want 3 (max)", got)
+		}
+	})
+
+	t.Run("halves the target once failure rate crosses the threshold", func(t *testing.T) {
+		sched := newAdaptiveScheduler(1, 20)
+		sched.target = 10
+
+		// 50% failures within the window, above aimdFailThreshold (30%).
+		for i := 0; i < aimdWindow; i++ {
+			sched.Report(i%2 == 0)
+		}
+		if got := sched.Target(); got != 5 {
+			t.Errorf("Target() = %d, want 5 (halved from 10)", got)
+		}
+	})
+
+	t.Run("does not go below min", func(t *testing.T) {
+		sched := newAdaptiveScheduler(3, 20)
+		sched.target = 4
+
+		for i := 0; i < aimdWindow; i++ {
+			sched.Report(false)
+		}
+		if got := sched.Target(); got != 3 {
+			t.Errorf("Target() = %d, want 3 (min)", got)
+		}
+	})
+
+	t.Run("stays put below a full window and below the failure threshold", func(t *testing.T) {
+		sched := newAdaptiveScheduler(2, 10)
+		for i := 0; i < aimdWindow-1; i++ {
+			sched.Report(true)
+		}
+		if got := sched.Target(); got != 2 {
+			t.Errorf("Target() = %d, want 2 (unchanged, window not yet full)", got)
+		}
+	})
+}
+
+func TestNewAdaptiveScheduler(t *testing.T) {
+	tests := []struct {
+		name           string
+		min, max       int
+		wantMin        int
+		wantMax        int
+		wantInitTarget int
+	}{
+		{"normal bounds", 2, 10, 2, 10, 2},
+		{"min below 1 clamps to 1", 0, 5, 1, 5, 1},
+		{"max below min clamps to min", 5, 2, 5, 5, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sched := newAdaptiveScheduler(tt.min, tt.max)
+			if sched.min != tt.wantMin {
+				t.Errorf("min = %d, want %d", sched.min, tt.wantMin)
+			}
+			if sched.max != tt.wantMax {
+				t.Errorf("max = %d, want %d", sched.max, tt.wantMax)
+			}
+			if got := sched.Target(); got != tt.wantInitTarget {
+				t.Errorf("initial Target() = %d, want %d", got, tt.wantInitTarget)
+			}
+		})
+	}
+}
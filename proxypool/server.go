@@ -0,0 +1,93 @@
+package proxypool
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Server exposes a Pool over HTTP so other tools can pull validated proxies
+// from it.
+type Server struct {
+	pool      *Pool
+	startedAt time.Time
+}
+
+// NewServer wraps pool in an http.Handler-compatible Server.
+func NewServer(pool *Pool) *Server {
+	return &Server{pool: pool, startedAt: time.Now()}
+}
+
+// Handler returns the mux routing GET /proxies, /proxies/random, /healthz,
+// and /metrics.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/proxies", s.handleProxies)
+	mux.HandleFunc("/proxies/random", s.handleRandom)
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/metrics", s.handleMetrics)
+	return mux
+}
+
+func (s *Server) handleProxies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.pool.Working())
+}
+
+func (s *Server) handleRandom(w http.ResponseWriter, r *http.Request) {
+	stat, ok := s.pool.Random()
+	if !ok {
+		http.Error(w, `{"error":"no working proxies"}`, http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stat)
+}
+
+func (s *Server) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	working := len(s.pool.Working())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":  "ok",
+		"uptime":  time.Since(s.startedAt).String(),
+		"working": working,
+		"tracked": len(s.pool.Snapshot()),
+	})
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	all := s.pool.Snapshot()
+	working := len(s.pool.Working())
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP sopan_proxies_tracked Total proxies tracked by the pool\n")
+	fmt.Fprintf(&b, "# TYPE sopan_proxies_tracked gauge\n")
+	fmt.Fprintf(&b, "sopan_proxies_tracked %d\n", len(all))
+
+	fmt.Fprintf(&b, "# HELP sopan_proxies_working Proxies currently considered working\n")
+	fmt.Fprintf(&b, "# TYPE sopan_proxies_working gauge\n")
+	fmt.Fprintf(&b, "sopan_proxies_working %d\n", working)
+
+	fmt.Fprintf(&b, "# HELP sopan_proxy_success_rate Rolling success rate per proxy\n")
+	fmt.Fprintf(&b, "# TYPE sopan_proxy_success_rate gauge\n")
+	for _, stat := range all {
+		fmt.Fprintf(&b, "sopan_proxy_success_rate{proxy=%q,type=%q} %f\n", stat.Proxy, stat.ProxyType, stat.SuccessRate())
+	}
+
+	fmt.Fprintf(&b, "# HELP sopan_proxy_ewma_latency_seconds Rolling average latency per proxy\n")
+	fmt.Fprintf(&b, "# TYPE sopan_proxy_ewma_latency_seconds gauge\n")
+	for _, stat := range all {
+		fmt.Fprintf(&b, "sopan_proxy_ewma_latency_seconds{proxy=%q,type=%q} %f\n", stat.Proxy, stat.ProxyType, stat.EWMALatency.Seconds())
+	}
+
+	fmt.Fprintf(&b, "# HELP sopan_proxy_consecutive_failures Consecutive failed checks per proxy\n")
+	fmt.Fprintf(&b, "# TYPE sopan_proxy_consecutive_failures gauge\n")
+	for _, stat := range all {
+		fmt.Fprintf(&b, "sopan_proxy_consecutive_failures{proxy=%q,type=%q} %d\n", stat.Proxy, stat.ProxyType, stat.ConsecutiveFailures)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write([]byte(b.String()))
+}
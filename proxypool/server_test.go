@@ -0,0 +1,29 @@
+package proxypool
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandleMetricsAppliesGeoFilter(t *testing.T) {
+	pool := NewPool(PoolConfig{
+		Proxies:   []string{"1.2.3.4:1080", "5.6.7.8:1080"},
+		GeoActive: true,
+		GeoFilter: GeoFilter{Countries: []string{"US"}},
+	})
+
+	pool.mu.Lock()
+	pool.applyResult(TestResult{Proxy: "1.2.3.4:1080", Success: true, Country: "US"})
+	pool.applyResult(TestResult{Proxy: "5.6.7.8:1080", Success: true, Country: "DE"})
+	pool.mu.Unlock()
+
+	srv := NewServer(pool)
+	rr := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "sopan_proxies_working 1\n") {
+		t.Errorf("metrics body = %q, want sopan_proxies_working to reflect the geo-filtered count (1)", body)
+	}
+}